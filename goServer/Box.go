@@ -3,8 +3,14 @@ package main
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"log"
@@ -15,15 +21,95 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
+// chunkedUploadThreshold : Box requires files at or above this size to use the upload session
+// (chunked) API rather than a single-shot multipart POST.
+const chunkedUploadThreshold = 50 * 1024 * 1024
+
+// defaultChunkWorkers : Default number of parts uploaded concurrently by UploadFileChunked.
+const defaultChunkWorkers = 4
+
+// tokenRefreshWindow : Refresh the cached access token once it is within this long of expiring,
+// rather than waiting for Box to reject a request with it.
+const tokenRefreshWindow = 60 * time.Second
+
+// pacer : Paces retries of transient Box API failures with exponential backoff.
+type pacer struct {
+	minSleep time.Duration
+	maxSleep time.Duration
+	decay    float64
+	retries  int
+}
+
+// newPacer : Creates a pacer with sane defaults for the Box API.
+func newPacer() *pacer {
+	return &pacer{
+		minSleep: 10 * time.Millisecond,
+		maxSleep: 2 * time.Second,
+		decay:    2,
+		retries:  5,
+	}
+}
+
+// sleepFor : Returns how long to sleep before the given (0-indexed) retry attempt.
+func (p *pacer) sleepFor(attempt int) time.Duration {
+	sleep := p.minSleep
+	for i := 0; i < attempt; i++ {
+		sleep = time.Duration(float64(sleep) * p.decay)
+		if sleep > p.maxSleep {
+			return p.maxSleep
+		}
+	}
+	return sleep
+}
+
+// shouldRetry : Reports whether statusCode is a transient failure worth retrying.
+func shouldRetry(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// BoxErrorBody : Box's JSON error representation, returned in the body of non-2xx responses.
+type BoxErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// BoxError : A typed error wrapping an HTTP status and Box's JSON error body, so callers can
+// distinguish conditions like "item_name_in_use" or "not_found" by Body.Code.
+type BoxError struct {
+	StatusCode int
+	Body       BoxErrorBody
+	Raw        []byte
+}
+
+func (e *BoxError) Error() string {
+	return fmt.Sprintf("box: status %d, code %q: %s", e.StatusCode, e.Body.Code, e.Body.Message)
+}
+
+// ErrHashMismatch : Returned when a SHA-1 computed locally during an upload or download does not
+// match the value Box reports for the same content.
+var ErrHashMismatch = errors.New("box: sha1 mismatch between local and server content")
+
 // Box : Reads in JWT and authenticates.
 type Box struct {
-	configFile  string
-	accessToken string
+	configFile   string
+	boxConfig    BoxJWTRequest
+	signedKey    *rsa.PrivateKey
+	accessToken  string
+	tokenExpiry  time.Time
+	tokenMu      sync.Mutex
+	pacer        *pacer
+	ChunkWorkers int
 }
 
 // BoxJWTRequest : Basic structure for a Box API JWT.
@@ -103,7 +189,7 @@ type FileVersion struct {
 // Entries : A more in-depth response containing more information about box objects.
 type Entries struct {
 	EntriesMini
-	Sha1              string         `json:"sha1 "`
+	Sha1              string         `json:"sha1"`
 	Description       string         `json:"description"`
 	Size              int            `json:"size"`
 	PathCollection    PathCollection `json:"path_collection,omitempty"`
@@ -202,67 +288,189 @@ type EmbeddedFile struct {
 	} `json:"expiring_embed_link"`
 }
 
-// NewBox : Creates a new server authenticator.
+// NewBox : Creates a new server authenticator. The JWT config and its RSA private key are read
+// and parsed once here, rather than on every access token request.
 func NewBox(file string) *Box {
-	box := &Box{file, ""}
+	box := &Box{configFile: file, ChunkWorkers: defaultChunkWorkers, pacer: newPacer()}
 	os.Setenv("authURL", "https://api.box.com/oauth2/token")
-	return box
-}
 
-// HTTPRequest : Runs an HTTP request via a defined method.
-func (box *Box) HTTPRequest(method string, url string, payload io.Reader, headers map[string]string) ([]byte, error) {
-	client := &http.Client{}
+	name, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Println(err)
+		return box
+	}
+	if err := json.Unmarshal(name, &box.boxConfig); err != nil {
+		log.Println(err)
+		return box
+	}
 
-	req, err := http.NewRequest(method, url, payload)
+	signedKey, err := jwt.ParseRSAPrivateKeyFromPEMWithPassword(
+		[]byte(box.boxConfig.BoxAppSettings.AppAuth.PrivateKey),
+		box.boxConfig.BoxAppSettings.AppAuth.Passphrase,
+	)
 	if err != nil {
 		log.Println(err)
-		return nil, err
+		return box
 	}
+	box.signedKey = signedKey
 
-	if headers != nil {
-		for k, v := range headers {
-			req.Header.Set(k, v)
-		}
-	} else {
-		if len(headers) == 0 {
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		}
+	return box
+}
+
+// ensureToken : Refreshes the cached access token if it is missing or within tokenRefreshWindow
+// of expiring. Safe for concurrent use.
+func (box *Box) ensureToken() error {
+	box.tokenMu.Lock()
+	defer box.tokenMu.Unlock()
+
+	if box.accessToken != "" && time.Now().Add(tokenRefreshWindow).Before(box.tokenExpiry) {
+		return nil
 	}
-	if box.accessToken != "" {
-		req.Header.Add("Authorization", "Bearer "+box.accessToken)
+
+	return box.requestAccessTokenLocked()
+}
+
+// token : Returns the current cached access token. Goes through tokenMu so it's safe to call
+// concurrently with ensureToken rewriting box.accessToken from another goroutine.
+func (box *Box) token() string {
+	box.tokenMu.Lock()
+	defer box.tokenMu.Unlock()
+	return box.accessToken
+}
+
+// HTTPRequest : Runs an HTTP request via a defined method, retrying transient failures through
+// box.pacer and returning a *BoxError for terminal non-2xx responses.
+func (box *Box) HTTPRequest(method string, url string, payload io.Reader, headers map[string]string) ([]byte, error) {
+	// Buffer the payload so it can be replayed across retries; io.Reader can only be read once.
+	var payloadBytes []byte
+	if payload != nil {
+		var err error
+		payloadBytes, err = ioutil.ReadAll(payload)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
 	}
 
-	response, err := client.Do(req)
+	response, err := box.doRequest(method, url, payloadBytes, headers)
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
 
 	respBytes, err := ioutil.ReadAll(response.Body)
-	response.Body.Close()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return respBytes, nil
+}
+
+// doRequest : Runs a single logical HTTP request, retrying transient failures through box.pacer
+// and refreshing the token once on a 401, the same as HTTPRequest. Returns the raw *http.Response
+// with its body unread on success, so callers can buffer it (HTTPRequest) or stream it
+// (DownloadFileStream) without duplicating this retry loop.
+func (box *Box) doRequest(method string, url string, payloadBytes []byte, headers map[string]string) (*http.Response, error) {
+	isAuthRequest := url == os.Getenv("authURL")
+	if !isAuthRequest {
+		if err := box.ensureToken(); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+
+	client := &http.Client{}
+	refreshed := false
+
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if payloadBytes != nil {
+			body = bytes.NewReader(payloadBytes)
+		}
+
+		req, err := http.NewRequest(method, url, body)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		if headers != nil {
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+		} else {
+			if len(headers) == 0 {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		}
+		// The token request itself runs with tokenMu held, so it must not take the lock again.
+		if !isAuthRequest {
+			if token := box.token(); token != "" {
+				req.Header.Add("Authorization", "Bearer "+token)
+			}
+		}
+
+		response, err := client.Do(req)
+		if err != nil {
+			if attempt < box.pacer.retries {
+				time.Sleep(box.pacer.sleepFor(attempt))
+				continue
+			}
+			return nil, err
+		}
+
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			return response, nil
+		}
+
+		respBytes, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
 
-	if response.StatusCode != 200 {
 		log.Println(" >> URL    :", url)
 		log.Println(" >> Status :", response.Status)
+
+		if response.StatusCode == http.StatusUnauthorized && !isAuthRequest && !refreshed {
+			refreshed = true
+			if err := box.RequestAccessToken(); err != nil {
+				log.Println(err)
+				return nil, err
+			}
+			continue
+		}
+
+		if shouldRetry(response.StatusCode) && attempt < box.pacer.retries {
+			sleep := box.pacer.sleepFor(attempt)
+			if response.StatusCode == http.StatusTooManyRequests {
+				if retryAfter := response.Header.Get("Retry-After"); retryAfter != "" {
+					if seconds, err := strconv.Atoi(retryAfter); err == nil {
+						sleep = time.Duration(seconds) * time.Second
+					}
+				}
+			}
+			time.Sleep(sleep)
+			continue
+		}
+
+		boxErr := &BoxError{StatusCode: response.StatusCode, Raw: respBytes}
+		json.Unmarshal(respBytes, &boxErr.Body)
+		return nil, boxErr
 	}
-	return respBytes, nil
 }
 
-// RequestAccessToken : Get valid ACCESS_TOKEN using JWT.
+// RequestAccessToken : Forces a fresh ACCESS_TOKEN using JWT, bypassing the cache. Most callers
+// should rely on HTTPRequest's automatic lazy refresh instead of calling this directly.
 func (box *Box) RequestAccessToken() error {
-	name, err := ioutil.ReadFile(box.configFile)
-	var boxConfig BoxJWTRequest
-
-	err = json.Unmarshal(name, &boxConfig)
+	box.tokenMu.Lock()
+	defer box.tokenMu.Unlock()
 
-	if err != nil {
-		log.Println(err)
-		return err
-	}
+	return box.requestAccessTokenLocked()
+}
 
+// requestAccessTokenLocked : Does the actual JWT-signed token request. Callers must hold tokenMu.
+func (box *Box) requestAccessTokenLocked() error {
 	// Create a unique 32 character long string.
 	rBytes := make([]byte, 32)
-	_, err = rand.Read(rBytes)
+	_, err := rand.Read(rBytes)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -271,31 +479,19 @@ func (box *Box) RequestAccessToken() error {
 
 	// Build the header. This includes the PublicKey as the ID.
 	token := jwt.New(jwt.SigningMethodRS512)
-	token.Header["keyid"] = boxConfig.BoxAppSettings.AppAuth.PublicKeyID
+	token.Header["keyid"] = box.boxConfig.BoxAppSettings.AppAuth.PublicKeyID
 
 	// Construct claims.
 	claims := token.Claims.(jwt.MapClaims)
-	claims["iss"] = boxConfig.BoxAppSettings.ClientID
-	claims["sub"] = boxConfig.EnterpriseID
+	claims["iss"] = box.boxConfig.BoxAppSettings.ClientID
+	claims["sub"] = box.boxConfig.EnterpriseID
 	claims["box_sub_type"] = "enterprise"
 	claims["aud"] = os.Getenv("authURL")
 	claims["jti"] = jti
 	claims["exp"] = time.Now().Add(time.Second * 10).Unix()
 
-	// Decrypt the PrivateKey using its passphrase.
-	signedKey, err := jwt.ParseRSAPrivateKeyFromPEMWithPassword(
-		[]byte(boxConfig.BoxAppSettings.AppAuth.PrivateKey),
-		boxConfig.BoxAppSettings.AppAuth.Passphrase,
-	)
-
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-
-	// Build the assertion from the signedKey and claims.
-	assertion, err := token.SignedString(signedKey)
-
+	// Build the assertion from the already-parsed signedKey and claims.
+	assertion, err := token.SignedString(box.signedKey)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -305,8 +501,8 @@ func (box *Box) RequestAccessToken() error {
 	payload := url.Values{}
 	payload.Add("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
 	payload.Add("assertion", assertion)
-	payload.Add("client_id", boxConfig.BoxAppSettings.ClientID)
-	payload.Add("client_secret", boxConfig.BoxAppSettings.ClientSecret)
+	payload.Add("client_id", box.boxConfig.BoxAppSettings.ClientID)
+	payload.Add("client_secret", box.boxConfig.BoxAppSettings.ClientSecret)
 
 	// Post the request to the Box API.
 	response, err := box.HTTPRequest("POST", os.Getenv("authURL"), bytes.NewBufferString(payload.Encode()), nil)
@@ -315,7 +511,7 @@ func (box *Box) RequestAccessToken() error {
 		return err
 	}
 
-	// Set the access token.
+	// Set the access token and its expiry.
 	var ar AccessResponse
 	err = json.Unmarshal(response, &ar)
 	if err != nil {
@@ -323,6 +519,7 @@ func (box *Box) RequestAccessToken() error {
 		return err
 	}
 	box.accessToken = ar.AccessToken
+	box.tokenExpiry = time.Now().Add(time.Duration(ar.ExpiresIn) * time.Second)
 
 	return nil
 }
@@ -330,9 +527,96 @@ func (box *Box) RequestAccessToken() error {
 ///////////////////////////////////////////////////////////////////////////////
 // File Functions
 
-// UploadFile : Creates an Access Token to the Box API, then uploads a given name to the specified folder.
-func (box *Box) UploadFile(file interface{}, newName string, folderID string) (*PathCollection, error) {
-	box.RequestAccessToken()
+// ConflictMode : How UploadFile should handle folderID already containing a file named newName.
+type ConflictMode int
+
+const (
+	// ConflictError : Return a typed *ErrConflict instead of uploading. The default.
+	ConflictError ConflictMode = iota
+	// ConflictOverwrite : Upload the content as a new version of the existing file.
+	ConflictOverwrite
+	// ConflictRename : Retry the upload under an auto-incremented " (n)" suffix.
+	ConflictRename
+	// ConflictSkip : Return the existing file's FileObject without uploading.
+	ConflictSkip
+)
+
+// maxConflictRenames : Bounds how many " (n)" suffixes ConflictRename will try before giving up.
+const maxConflictRenames = 20
+
+// UploadOptions : Configures how UploadFile behaves when folderID already contains a file with
+// the same name.
+type UploadOptions struct {
+	Conflict ConflictMode
+}
+
+// ErrConflict : Returned by UploadFile when ConflictError applies and folderID already contains
+// a file named newName.
+type ErrConflict struct {
+	Name     string
+	FolderID string
+}
+
+func (e *ErrConflict) Error() string {
+	return fmt.Sprintf("box: %q already exists in folder %s", e.Name, e.FolderID)
+}
+
+// conflictBody : The subset of Box's 409 item_name_in_use error body identifying the existing item.
+type conflictBody struct {
+	ContextInfo struct {
+		Conflicts FileObject `json:"conflicts"`
+	} `json:"context_info"`
+}
+
+// postMultipart : Posts contents as a multipart/form-data file upload to url (either the
+// /files/content create endpoint or a /files/{id}/content new-version endpoint), verifying the
+// returned SHA-1 against what was actually sent.
+func (box *Box) postMultipart(url string, contents []byte, name string) (*PathCollection, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		log.Println(err)
+	}
+	hasher := sha1.New()
+	io.Copy(io.MultiWriter(part, hasher), bytes.NewReader(contents))
+
+	err = writer.WriteField("filename", name)
+	if err != nil {
+		log.Println(err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		log.Println(err)
+	}
+
+	headers := make(map[string]string)
+	headers["Content-Type"] = writer.FormDataContentType()
+	headers["Content-Length"] = strconv.Itoa(body.Len())
+
+	response, err := box.HTTPRequest("POST", url, body, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	fileObject := &PathCollection{}
+	json.Unmarshal(response, &fileObject)
+
+	if len(fileObject.Entries) > 0 && fileObject.Entries[0].Sha1 != hex.EncodeToString(hasher.Sum(nil)) {
+		return nil, ErrHashMismatch
+	}
+
+	return fileObject, nil
+}
+
+// UploadFile : Uploads a given name to the specified folder, honoring opts.Conflict if folderID
+// already contains a file named newName. Decides chunked vs single-shot from the size on disk,
+// without reading a large file into memory first. opts.Conflict applies to single-shot uploads
+// only: content at or above chunkedUploadThreshold goes through UploadFileChunked, and a name
+// conflict there is returned as the upload session's *BoxError.
+func (box *Box) UploadFile(file interface{}, newName string, folderID string, opts UploadOptions) (*PathCollection, error) {
 
 	t := reflect.TypeOf(file)
 
@@ -346,62 +630,331 @@ func (box *Box) UploadFile(file interface{}, newName string, folderID string) (*
 		newName = name
 	}
 
-	var contents []byte
 	if t.Name() == "" {
-		contents = file.([]byte)
-	} else {
-		f, err := os.Open(name)
-		if err != nil {
-			log.Println(err)
+		contents := file.([]byte)
+		if int64(len(contents)) >= chunkedUploadThreshold {
+			return box.uploadChunked(bytes.NewReader(contents), int64(len(contents)), newName, folderID)
 		}
-		defer f.Close()
+		return box.uploadSingleShot(contents, name, newName, folderID, opts)
+	}
 
-		contents, err = ioutil.ReadAll(f)
-		if err != nil {
-			log.Println(err)
+	f, err := os.Open(name)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if info.Size() >= chunkedUploadThreshold {
+		return box.uploadChunked(f, info.Size(), newName, folderID)
+	}
+
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return box.uploadSingleShot(contents, name, newName, folderID, opts)
+}
+
+// uploadChunked : Runs UploadFileChunked and wraps its result as a PathCollection to match
+// UploadFile's return type.
+func (box *Box) uploadChunked(reader io.Reader, size int64, newName string, folderID string) (*PathCollection, error) {
+	fileObject, err := box.UploadFileChunked(reader, size, newName, folderID, chunkedUploadThreshold)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &PathCollection{TotalCount: 1, Entries: []Entries{{EntriesMini: EntriesMini{Type: fileObject.Type, ID: fileObject.ID, Name: fileObject.Name}}}}, nil
+}
+
+// uploadSingleShot : Does the single-shot multipart upload and, on a 409 item_name_in_use
+// response, honors opts.Conflict.
+func (box *Box) uploadSingleShot(contents []byte, name string, newName string, folderID string, opts UploadOptions) (*PathCollection, error) {
+	createURL := "https://upload.box.com/api/2.0/files/content?attributes={%22name%22:%22" + newName + "%22,%20%22parent%22:{%22id%22:%22" + folderID + "%22}}"
+	fileObject, err := box.postMultipart(createURL, contents, name)
+	if err == nil {
+		return fileObject, nil
+	}
+
+	boxErr, isBoxErr := err.(*BoxError)
+	if !isBoxErr || boxErr.StatusCode != http.StatusConflict {
+		log.Println(err)
+		return nil, err
+	}
+
+	var conflict conflictBody
+	json.Unmarshal(boxErr.Raw, &conflict)
+	existingID := conflict.ContextInfo.Conflicts.ID
+
+	switch opts.Conflict {
+	case ConflictSkip:
+		if existingID == "" {
+			return nil, err
+		}
+		existing, getErr := box.GetFileInfo(existingID)
+		if getErr != nil {
+			log.Println(getErr)
+			return nil, getErr
 		}
+		return &PathCollection{TotalCount: 1, Entries: []Entries{{EntriesMini: EntriesMini{Type: existing.Type, ID: existing.ID, Name: existing.Name}}}}, nil
+
+	case ConflictOverwrite:
+		if existingID == "" {
+			return nil, err
+		}
+		return box.postMultipart("https://upload.box.com/api/2.0/files/"+existingID+"/content", contents, name)
+
+	case ConflictRename:
+		for n := 1; n <= maxConflictRenames; n++ {
+			renameURL := "https://upload.box.com/api/2.0/files/content?attributes={%22name%22:%22" + fmt.Sprintf("%s (%d)", newName, n) + "%22,%20%22parent%22:{%22id%22:%22" + folderID + "%22}}"
+			fileObject, renameErr := box.postMultipart(renameURL, contents, name)
+			if renameErr == nil {
+				return fileObject, nil
+			}
+			if renameBoxErr, ok := renameErr.(*BoxError); !ok || renameBoxErr.StatusCode != http.StatusConflict {
+				log.Println(renameErr)
+				return nil, renameErr
+			}
+		}
+		return nil, err
+
+	default:
+		return nil, &ErrConflict{Name: newName, FolderID: folderID}
 	}
+}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+// UploadSession : A Box upload session, created to perform a chunked/resumable upload.
+type UploadSession struct {
+	ID               string `json:"id"`
+	Type             string `json:"type"`
+	NumPartsUploaded int    `json:"num_parts_processed"`
+	PartSize         int64  `json:"part_size"`
+	TotalParts       int    `json:"total_parts"`
+	SessionExpiresAt string `json:"session_expires_at"`
+}
 
-	part, err := writer.CreateFormFile("file", name)
+// UploadPart : A single part of a chunked upload, as returned by Box after it is uploaded.
+type UploadPart struct {
+	PartID string `json:"part_id"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Sha1   string `json:"sha1"`
+}
+
+// uploadPartResponse : Wraps the "part" field returned by the upload part endpoint.
+type uploadPartResponse struct {
+	Part UploadPart `json:"part"`
+}
+
+// commitUploadResponse : Wraps the "entries" field returned by the commit endpoint.
+type commitUploadResponse struct {
+	TotalCount int          `json:"total_count"`
+	Entries    []FileObject `json:"entries"`
+}
+
+// UploadFileChunked : Uploads a large file to the folder with 'ID' folderID using Box's upload
+// session API, reading and uploading parts concurrently. Parts are the size the session reports;
+// partSize is used only when the session does not report one. Prefer UploadFile, which
+// calls this automatically once a file reaches Box's chunked-upload threshold.
+func (box *Box) UploadFileChunked(reader io.Reader, size int64, name string, folderID string, partSize int64) (*FileObject, error) {
+
+	session, err := box.createUploadSession(size, name, folderID, partSize)
 	if err != nil {
 		log.Println(err)
+		return nil, err
 	}
-	part.Write(contents)
 
-	err = writer.WriteField("filename", name)
+	numParts := int((size + session.PartSize - 1) / session.PartSize)
+	parts := make([]UploadPart, numParts)
+	overallHash := sha1.New()
+
+	workers := box.ChunkWorkers
+	if workers < 1 {
+		workers = defaultChunkWorkers
+	}
+
+	// jobs is bounded so at most ~workers parts are buffered in memory at once, rather than the
+	// whole file; the reader only stays ahead of the upload pool by one part per worker.
+	type uploadJob struct {
+		index int
+		buf   []byte
+	}
+	jobs := make(chan uploadJob, workers)
+	errs := make(chan error, numParts)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				part, err := box.uploadPart(session, j.buf, int64(j.index)*session.PartSize, size)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				parts[j.index] = part
+			}
+		}()
+	}
+
+	var readErr error
+	for i := 0; i < numParts; i++ {
+		offset := int64(i) * session.PartSize
+		length := session.PartSize
+		if offset+length > size {
+			length = size - offset
+		}
+
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			readErr = err
+			break
+		}
+		overallHash.Write(buf)
+		jobs <- uploadJob{index: i, buf: buf}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if readErr != nil {
+		log.Println(readErr)
+		return nil, readErr
+	}
+	if err := <-errs; err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	fileSha1 := hex.EncodeToString(overallHash.Sum(nil))
+	fileObject, err := box.commitUploadSession(session, parts, fileSha1)
 	if err != nil {
 		log.Println(err)
+		return nil, err
+	}
+	if fileObject.Sha1 != fileSha1 {
+		return nil, ErrHashMismatch
 	}
 
-	err = writer.Close()
+	return fileObject, nil
+}
+
+// createUploadSession : Creates an upload session for a file of the given size and name, returning
+// the session ID and the part size Box wants each part uploaded in.
+func (box *Box) createUploadSession(size int64, name string, folderID string, partSize int64) (*UploadSession, error) {
+	body := strings.NewReader(`{"folder_id":"` + folderID + `","file_name":"` + name + `","file_size":` + strconv.FormatInt(size, 10) + `}`)
+
+	response, err := box.HTTPRequest("POST", "https://upload.box.com/api/2.0/files/upload_sessions", body, map[string]string{"Content-Type": "application/json"})
 	if err != nil {
 		log.Println(err)
+		return nil, err
 	}
 
-	headers := make(map[string]string)
-	headers["Content-Type"] = writer.FormDataContentType()
-	headers["Content-Length"] = string(body.Len())
+	session := &UploadSession{}
+	if err := json.Unmarshal(response, session); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if session.PartSize == 0 {
+		session.PartSize = partSize
+	}
 
-	response, err := box.HTTPRequest("POST",
-		"https://upload.box.com/api/2.0/files/content?attributes={%22name%22:%22"+newName+"%22,%20%22parent%22:{%22id%22:%22"+folderID+"%22}}",
-		body, headers)
+	return session, nil
+}
+
+// uploadPart : Uploads a single part of a chunked upload, identified by its byte offset within the
+// whole file, and returns the part descriptor Box assigns it.
+func (box *Box) uploadPart(session *UploadSession, part []byte, offset int64, totalSize int64) (UploadPart, error) {
+	hash := sha1.Sum(part)
+
+	headers := map[string]string{
+		"Content-Type":  "application/octet-stream",
+		"Content-Range": fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(part))-1, totalSize),
+		"Digest":        "sha=" + base64.StdEncoding.EncodeToString(hash[:]),
+	}
+
+	response, err := box.HTTPRequest("PUT", "https://upload.box.com/api/2.0/files/upload_sessions/"+session.ID, bytes.NewReader(part), headers)
+	if err != nil {
+		log.Println(err)
+		return UploadPart{}, err
+	}
+
+	var partResponse uploadPartResponse
+	if err := json.Unmarshal(response, &partResponse); err != nil {
+		log.Println(err)
+		return UploadPart{}, err
+	}
+
+	return partResponse.Part, nil
+}
+
+// commitUploadSession : Finalizes a chunked upload once every part has been uploaded, supplying the
+// ordered part list and the whole-file SHA-1, and returns the resulting FileObject.
+func (box *Box) commitUploadSession(session *UploadSession, parts []UploadPart, fileSha1 string) (*FileObject, error) {
+	payload := struct {
+		Parts []UploadPart `json:"parts"`
+	}{parts}
+
+	body, err := json.Marshal(payload)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	fileObject := &PathCollection{}
-	json.Unmarshal(response, &fileObject)
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"Digest":       "sha=" + base64.StdEncoding.EncodeToString(mustHexDecode(fileSha1)),
+	}
 
-	return fileObject, nil
+	commitURL := "https://upload.box.com/api/2.0/files/upload_sessions/" + session.ID + "/commit"
+
+	// Box can answer the commit with a 2xx and no entries while it's still processing the parts
+	// (a 202 Accepted, normally paired with a Retry-After). Poll the same endpoint through the
+	// pacer rather than treating an empty response as success.
+	for attempt := 0; ; attempt++ {
+		response, err := box.HTTPRequest("POST", commitURL, bytes.NewReader(body), headers)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		commit := &commitUploadResponse{}
+		if err := json.Unmarshal(response, commit); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if len(commit.Entries) > 0 {
+			return &commit.Entries[0], nil
+		}
+
+		if attempt >= box.pacer.retries {
+			return nil, fmt.Errorf("box: commit upload session %s returned no entries after %d attempts", session.ID, attempt+1)
+		}
+		time.Sleep(box.pacer.sleepFor(attempt))
+	}
+}
+
+// mustHexDecode : Decodes a hex-encoded SHA-1 digest. The digest is always produced internally by
+// crypto/sha1, so a decode failure here indicates a programming error rather than bad input.
+func mustHexDecode(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return b
 }
 
 // GetFileInfo : Returns information about the file with 'ID' fileID.
 func (box *Box) GetFileInfo(fileID string) (*FileObject, error) {
-	box.RequestAccessToken()
 	response, err := box.HTTPRequest("GET", "https://api.box.com/2.0/files/"+fileID, nil, nil)
 	if err != nil {
 		log.Println(err)
@@ -415,7 +968,6 @@ func (box *Box) GetFileInfo(fileID string) (*FileObject, error) {
 
 // GetEmbedLink : Returns information about the file with 'ID' fileID.
 func (box *Box) GetEmbedLink(fileID string) (*EmbeddedFile, error) {
-	box.RequestAccessToken()
 	response, err := box.HTTPRequest("GET", "https://api.box.com/2.0/files/"+fileID+"?fields=expiring_embed_link", nil, nil)
 	if err != nil {
 		log.Println(err)
@@ -427,16 +979,65 @@ func (box *Box) GetEmbedLink(fileID string) (*EmbeddedFile, error) {
 	return fileObject, nil
 }
 
-// DownloadFile : Downloads a file with 'ID' fileID.
+// RangeOptions : Specifies a byte range for a partial/resumable download, per the HTTP
+// Range: bytes=Start-End header Box honors on file content requests.
+type RangeOptions struct {
+	Start int64
+	End   int64
+}
+
+// DownloadFileStream : Copies fileID's content straight to w, optionally restricted to opts'
+// byte range. When verify is true and opts is nil, the full download is hashed and checked
+// against GetFileInfo(fileID).Sha1, returning ErrHashMismatch on mismatch. Returns bytes written.
+func (box *Box) DownloadFileStream(fileID string, w io.Writer, opts *RangeOptions, verify bool) (int64, error) {
+	headers := map[string]string{}
+	if opts != nil {
+		headers["Range"] = fmt.Sprintf("bytes=%d-%d", opts.Start, opts.End)
+	}
+
+	response, err := box.doRequest("GET", "https://api.box.com/2.0/files/"+fileID+"/content", nil, headers)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	dest := w
+	var hasher hash.Hash
+	if verify && opts == nil {
+		hasher = sha1.New()
+		dest = io.MultiWriter(w, hasher)
+	}
+
+	written, err := io.Copy(dest, response.Body)
+	if err != nil {
+		log.Println(err)
+		return written, err
+	}
+
+	if hasher != nil {
+		info, err := box.GetFileInfo(fileID)
+		if err != nil {
+			log.Println(err)
+			return written, err
+		}
+		if hex.EncodeToString(hasher.Sum(nil)) != info.Sha1 {
+			return written, ErrHashMismatch
+		}
+	}
+
+	return written, nil
+}
+
+// DownloadFile : Downloads a file with 'ID' fileID, streaming it straight to disk and verifying
+// its SHA-1 against Box's once the download completes.
 func (box *Box) DownloadFile(fileID string, location string) error {
-	box.RequestAccessToken()
-	response, err := box.HTTPRequest("GET", "https://api.box.com/2.0/files/"+fileID+"/content", nil, nil)
+	fInfo, err := box.GetFileInfo(fileID)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	fInfo, err := box.GetFileInfo(fileID)
 	file, err := os.Create(location + fInfo.Name)
 	if err != nil {
 		log.Println(err)
@@ -444,17 +1045,26 @@ func (box *Box) DownloadFile(fileID string, location string) error {
 	}
 	defer file.Close()
 
-	_, err = file.Write(response)
-	if err != nil {
+	if _, err := box.DownloadFileStream(fileID, file, nil, true); err != nil {
 		log.Println(err)
 		return err
 	}
 	return nil
 }
 
+// Hash : Returns the server-side SHA-1 of the file with 'ID' fileID, for comparison against a
+// locally computed digest.
+func (box *Box) Hash(fileID string) (string, error) {
+	info, err := box.GetFileInfo(fileID)
+	if err != nil {
+		log.Println(err)
+		return "", err
+	}
+	return info.Sha1, nil
+}
+
 // DeleteFile : Deletes a file in a specific folder with 'ID" fileID.
 func (box *Box) DeleteFile(fileID string, etag string) error {
-	box.RequestAccessToken()
 	headers := make(map[string]string)
 	headers["If-Match"] = etag
 	_, err := box.HTTPRequest("DELETE", "https://api.box.com/2.0/files/"+fileID, nil, headers)
@@ -470,7 +1080,6 @@ func (box *Box) DeleteFile(fileID string, etag string) error {
 
 // CreateFolder : Creates a new folder under the parent folder that has 'ID' parentFolderID.
 func (box *Box) CreateFolder(name string, parentFolderID string) (*FolderObject, error) {
-	box.RequestAccessToken()
 	body := strings.NewReader(`{"name":"` + name + `", "parent": {"id": "` + parentFolderID + `"}}`)
 
 	response, err := box.HTTPRequest("POST", "https://api.box.com/2.0/folders", body, nil)
@@ -486,7 +1095,6 @@ func (box *Box) CreateFolder(name string, parentFolderID string) (*FolderObject,
 
 // GetFolderItems : Returns all the items contained inside the folder with 'ID' folderID.
 func (box *Box) GetFolderItems(folderID string, limit int, offset int) (*ItemCollection, error) {
-	box.RequestAccessToken()
 
 	response, err := box.HTTPRequest("GET", "https://api.box.com/2.0/folders/"+folderID+"/items?limit="+strconv.Itoa(limit)+"&offset="+strconv.Itoa(offset), nil, nil)
 	if err != nil {
@@ -499,9 +1107,34 @@ func (box *Box) GetFolderItems(folderID string, limit int, offset int) (*ItemCol
 	return items, nil
 }
 
+// UpdateFolder : Renames and/or moves the folder with 'ID' folderID. Pass an empty name or
+// newParentID to leave that attribute unchanged.
+func (box *Box) UpdateFolder(folderID string, name string, newParentID string) (*FolderObject, error) {
+	fields := ""
+	if name != "" {
+		fields += `"name":"` + name + `"`
+	}
+	if newParentID != "" {
+		if fields != "" {
+			fields += ","
+		}
+		fields += `"parent":{"id":"` + newParentID + `"}`
+	}
+	body := strings.NewReader("{" + fields + "}")
+
+	response, err := box.HTTPRequest("PUT", "https://api.box.com/2.0/folders/"+folderID, body, nil)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	folderObject := &FolderObject{}
+	json.Unmarshal(response, &folderObject)
+
+	return folderObject, nil
+}
+
 // DeleteFolder : Deletes the folder with 'ID' folderID.
 func (box *Box) DeleteFolder(folderID string) error {
-	box.RequestAccessToken()
 	_, err := box.HTTPRequest("DELETE", "https://api.box.com/2.0/folders/"+folderID+"?recursive=true", nil, nil)
 	if err != nil {
 		log.Println(err)
@@ -509,3 +1142,85 @@ func (box *Box) DeleteFolder(folderID string) error {
 	}
 	return nil
 }
+
+///////////////////////////////////////////////////////////////////////////////
+// Shared Link Functions
+
+// SharedLinkOptions : Configures a shared link created via CreateSharedLink.
+type SharedLinkOptions struct {
+	Access      string // "open", "company", or "collaborators"
+	Password    string
+	UnsharedAt  string // ISO 8601 expiry, e.g. "2026-12-31T00:00:00-08:00"
+	CanDownload bool
+}
+
+// sharedLinkBody : Wraps the "shared_link" field sent to and returned by the files/folders PUT
+// endpoints when creating, updating, or removing a shared link.
+type sharedLinkBody struct {
+	SharedLink SharedLink `json:"shared_link"`
+}
+
+// CreateSharedLink : Creates a shared link on the file or folder with 'ID' id (itemType is
+// "files" or "folders"), configured per opts.
+func (box *Box) CreateSharedLink(itemType string, id string, opts SharedLinkOptions) (*SharedLink, error) {
+	link := map[string]interface{}{
+		"access": opts.Access,
+		"permissions": map[string]bool{
+			"can_download": opts.CanDownload,
+		},
+	}
+	if opts.Password != "" {
+		link["password"] = opts.Password
+	}
+	if opts.UnsharedAt != "" {
+		link["unshared_at"] = opts.UnsharedAt
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"shared_link": link})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	response, err := box.HTTPRequest("PUT", "https://api.box.com/2.0/"+itemType+"/"+id, bytes.NewReader(payload), map[string]string{"Content-Type": "application/json"})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	body := &sharedLinkBody{}
+	json.Unmarshal(response, body)
+
+	return &body.SharedLink, nil
+}
+
+// RemoveSharedLink : Revokes the shared link on the file or folder with 'ID' id (itemType is
+// "files" or "folders").
+func (box *Box) RemoveSharedLink(itemType string, id string) error {
+	_, err := box.HTTPRequest("PUT", "https://api.box.com/2.0/"+itemType+"/"+id, strings.NewReader(`{"shared_link":null}`), map[string]string{"Content-Type": "application/json"})
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// GetSharedItem : Resolves a shared URL (optionally password-protected) back to the item it
+// points at.
+func (box *Box) GetSharedItem(sharedURL string, password string) (*EntriesMini, error) {
+	boxAPI := "shared_link=" + sharedURL
+	if password != "" {
+		boxAPI += "&shared_link_password=" + password
+	}
+
+	response, err := box.HTTPRequest("GET", "https://api.box.com/2.0/shared_items", nil, map[string]string{"BoxApi": boxAPI})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	item := &EntriesMini{}
+	json.Unmarshal(response, item)
+
+	return item, nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// TestEntriesSha1FromUploadResponse guards against the "sha1 " (trailing space) tag typo that
+// made every upload's SHA-1 check read as an empty string.
+func TestEntriesSha1FromUploadResponse(t *testing.T) {
+	contents := []byte("hello, box")
+	sum := sha1.Sum(contents)
+	want := hex.EncodeToString(sum[:])
+
+	fixture := []byte(`{
+		"total_count": 1,
+		"entries": [
+			{"type": "file", "id": "12345", "name": "hello.txt", "sha1": "` + want + `"}
+		]
+	}`)
+
+	var collection PathCollection
+	if err := json.Unmarshal(fixture, &collection); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(collection.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(collection.Entries))
+	}
+	if got := collection.Entries[0].Sha1; got != want {
+		t.Fatalf("Entries[0].Sha1 = %q, want %q", got, want)
+	}
+}
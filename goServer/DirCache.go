@@ -0,0 +1,228 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// DirCache : Maintains a bidirectional path <-> ID map for a Box account, so callers can address
+// folders and files by a human path like "/a/b/c.mp4" instead of walking GetFolderItems
+// themselves for every lookup.
+type DirCache struct {
+	box      *Box
+	rootID   string
+	mu       sync.RWMutex
+	dirToID  map[string]string
+	idToDir  map[string]string
+	createMu sync.Mutex
+}
+
+// NewDirCache : Creates a DirCache addressing paths relative to the folder with 'ID' rootID.
+func NewDirCache(box *Box, rootID string) *DirCache {
+	return &DirCache{
+		box:     box,
+		rootID:  rootID,
+		dirToID: map[string]string{"/": rootID},
+		idToDir: map[string]string{rootID: "/"},
+	}
+}
+
+// cleanPath : Strips leading/trailing slashes so paths can be compared and joined consistently.
+func cleanPath(path string) string {
+	return strings.Trim(path, "/")
+}
+
+// folderItemsPageSize : Entries requested per GetFolderItems call while paging through folderEntries.
+const folderItemsPageSize = 1000
+
+// folderEntries : Pages through GetFolderItems until every entry in folderID has been collected.
+func (d *DirCache) folderEntries(folderID string) ([]EntriesMini, error) {
+	var all []EntriesMini
+	for offset := 0; ; offset += folderItemsPageSize {
+		items, err := d.box.GetFolderItems(folderID, folderItemsPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items.Entries...)
+		if len(all) >= items.TotalCount || len(items.Entries) == 0 {
+			break
+		}
+	}
+	return all, nil
+}
+
+// FindDir : Resolves path to a folder ID, walking the cache one segment at a time and only
+// issuing a GetFolderItems call for segments that aren't already cached. If create is true,
+// folders missing along the way are created via CreateFolder.
+func (d *DirCache) FindDir(path string, create bool) (string, error) {
+	path = cleanPath(path)
+	if path == "" {
+		return d.rootID, nil
+	}
+
+	walked := "/" + path
+	d.mu.RLock()
+	id, ok := d.dirToID[walked]
+	d.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	parentID := d.rootID
+	walked = ""
+	for _, segment := range strings.Split(path, "/") {
+		walked += "/" + segment
+
+		d.mu.RLock()
+		id, ok := d.dirToID[walked]
+		d.mu.RUnlock()
+		if ok {
+			parentID = id
+			continue
+		}
+
+		found, err := d.resolveOrCreateSegment(parentID, walked, segment, create)
+		if err != nil {
+			return "", err
+		}
+
+		parentID = found
+	}
+
+	return parentID, nil
+}
+
+// resolveOrCreateSegment : Resolves segment (a single path component under parentID, cached at
+// walked) to its folder ID, creating it if create is true and it doesn't exist. Serialized by
+// createMu, re-checking the cache once inside it, so two goroutines racing to resolve the same
+// missing segment create it at most once instead of both calling CreateFolder.
+func (d *DirCache) resolveOrCreateSegment(parentID string, walked string, segment string, create bool) (string, error) {
+	d.createMu.Lock()
+	defer d.createMu.Unlock()
+
+	d.mu.RLock()
+	id, ok := d.dirToID[walked]
+	d.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	entries, err := d.folderEntries(parentID)
+	if err != nil {
+		return "", err
+	}
+
+	found := ""
+	for _, entry := range entries {
+		if entry.Type == "folder" && entry.Name == segment {
+			found = entry.ID
+			break
+		}
+	}
+
+	if found == "" {
+		if !create {
+			return "", os.ErrNotExist
+		}
+		folder, err := d.box.CreateFolder(segment, parentID)
+		if err != nil {
+			return "", err
+		}
+		found = folder.ID
+	}
+
+	d.mu.Lock()
+	d.dirToID[walked] = found
+	d.idToDir[found] = walked
+	d.mu.Unlock()
+
+	return found, nil
+}
+
+// FindFile : Resolves path to a file's ID and its parent folder's ID.
+func (d *DirCache) FindFile(path string) (id string, parentID string, err error) {
+	path = cleanPath(path)
+	if path == "" {
+		return "", "", os.ErrNotExist
+	}
+
+	dir, name := "", path
+	if idx := strings.LastIndex(path, "/"); idx >= 0 {
+		dir, name = path[:idx], path[idx+1:]
+	}
+
+	parentID, err = d.FindDir(dir, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	entries, err := d.folderEntries(parentID)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, entry := range entries {
+		if entry.Type == "file" && entry.Name == name {
+			return entry.ID, parentID, nil
+		}
+	}
+
+	return "", "", os.ErrNotExist
+}
+
+// FlushDir : Invalidates path and everything cached underneath it, forcing the next
+// FindDir/FindFile call for any of those paths to re-resolve against the Box API.
+func (d *DirCache) FlushDir(path string) {
+	path = "/" + cleanPath(path)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for cached, id := range d.dirToID {
+		if cached == path || strings.HasPrefix(cached, path+"/") {
+			delete(d.dirToID, cached)
+			delete(d.idToDir, id)
+		}
+	}
+}
+
+// DeleteDir : Deletes the folder at path through the underlying Box client and flushes its cache
+// entries, since DeleteFolder invalidates anything the cache knows about that subtree.
+func (d *DirCache) DeleteDir(path string) error {
+	id, err := d.FindDir(path, false)
+	if err != nil {
+		return err
+	}
+	if err := d.box.DeleteFolder(id); err != nil {
+		return err
+	}
+	d.FlushDir(path)
+	return nil
+}
+
+// RenameDir : Renames or moves the folder at oldPath to newPath through the underlying Box
+// client, then flushes oldPath so it and FindDir/FindFile re-resolve newPath from scratch.
+func (d *DirCache) RenameDir(oldPath string, newPath string) error {
+	id, err := d.FindDir(oldPath, false)
+	if err != nil {
+		return err
+	}
+
+	newClean := cleanPath(newPath)
+	newParentPath, newName := "", newClean
+	if idx := strings.LastIndex(newClean, "/"); idx >= 0 {
+		newParentPath, newName = newClean[:idx], newClean[idx+1:]
+	}
+	newParentID, err := d.FindDir(newParentPath, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.box.UpdateFolder(id, newName, newParentID); err != nil {
+		return err
+	}
+
+	d.FlushDir(oldPath)
+	return nil
+}